@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiError is the structured error body returned by the JSON API in place
+// of a bare status code.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warnf("api: error encoding response: %s", err)
+	}
+}
+
+// apiDevicesHandler serves GET /api/v1/devices.
+func apiDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	data := scanner.Devices()
+	for i := range data {
+		data[i].Since = since(data[i].Detected)
+	}
+	writeJSON(w, data)
+}
+
+// apiDeviceHandler serves GET /api/v1/devices/{addr}.
+func apiDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	addr := strings.TrimPrefix(r.URL.Path, "/api/v1/devices/")
+	if addr == "" {
+		writeAPIError(w, http.StatusBadRequest, "missing device address")
+		return
+	}
+	device, ok := scanner.Device(addr)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "device not found: "+addr)
+		return
+	}
+	device.Since = since(device.Detected)
+	writeJSON(w, device)
+}
+
+// scanRequest is the JSON body POST /api/v1/scan accepts.
+type scanRequest struct {
+	Duration string `json:"duration"`
+}
+
+// apiScanHandler serves POST /api/v1/scan, starting a scan pass for the
+// requested duration (falling back to -d when omitted).
+func apiScanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	req := scanRequest{}
+	if r.Body != nil {
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			writeAPIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	d := *dur
+	if req.Duration != "" {
+		parsed, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid duration: "+err.Error())
+			return
+		}
+		d = parsed
+	}
+
+	if err := scanner.Start(context.Background(), d); err != nil {
+		writeAPIError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, map[string]string{"status": "started"})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// appleCompanyID is the Bluetooth SIG company identifier Apple advertises
+// iBeacon frames under.
+const appleCompanyID = 0x004C
+
+// eddystoneUUID is the 16-bit Eddystone service UUID, as formatUUID16 would
+// render it.
+const eddystoneUUID = "feaa"
+
+// Beacon is a decoded iBeacon or Eddystone frame found in a device's
+// manufacturer data or service data.
+type Beacon struct {
+	Type string `json:"type"` // "ibeacon", "eddystone-uid", "eddystone-url", "eddystone-tlm" or "eddystone-eid"
+
+	// iBeacon fields.
+	ProximityUUID string  `json:"proximityuuid,omitempty"`
+	Major         uint16  `json:"major,omitempty"`
+	Minor         uint16  `json:"minor,omitempty"`
+	MeasuredPower int8    `json:"measuredpower,omitempty"`
+	Distance      float64 `json:"distance,omitempty"`
+
+	// Eddystone UID/EID fields.
+	Namespace string `json:"namespace,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+
+	// Eddystone URL field.
+	URL string `json:"url,omitempty"`
+
+	// Eddystone TLM fields.
+	Voltage     uint16  `json:"voltage,omitempty"`
+	Temperature float64 `json:"temperature,omitempty"`
+	AdvCnt      uint32  `json:"advcnt,omitempty"`
+	SecCnt      uint32  `json:"seccnt,omitempty"`
+}
+
+// decodeBeacon inspects gap for a recognized iBeacon or Eddystone frame,
+// computing estimated distance (for iBeacon, from rssi) where applicable.
+func decodeBeacon(gap GAPData, rssi int) *Beacon {
+	if b, ok := decodeIBeacon(gap.ManufacturerData); ok {
+		b.Distance = estimateDistance(b.MeasuredPower, rssi)
+		return &b
+	}
+	if b, ok := decodeEddystone(gap.ServiceData); ok {
+		return &b
+	}
+	return nil
+}
+
+// decodeIBeacon looks for Apple's iBeacon prefix (0x02 0x15) in manufacturer
+// data keyed by appleCompanyID: 16-byte proximity UUID, 2-byte major,
+// 2-byte minor (all big-endian) and a 1-byte signed measured power.
+func decodeIBeacon(manufacturerData map[uint16][]byte) (Beacon, bool) {
+	raw, ok := manufacturerData[appleCompanyID]
+	if !ok || len(raw) < 23 || raw[0] != 0x02 || raw[1] != 0x15 {
+		return Beacon{}, false
+	}
+	return Beacon{
+		Type:          "ibeacon",
+		ProximityUUID: formatUUIDBigEndian(raw[2:18]),
+		Major:         binary.BigEndian.Uint16(raw[18:20]),
+		Minor:         binary.BigEndian.Uint16(raw[20:22]),
+		MeasuredPower: int8(raw[22]),
+	}, true
+}
+
+// estimateDistance applies the standard RSSI-to-distance path-loss
+// approximation with a path-loss exponent n of roughly 2 (free space).
+func estimateDistance(measuredPower int8, rssi int) float64 {
+	return math.Pow(10, (float64(measuredPower)-float64(rssi))/(10*2))
+}
+
+// decodeEddystone looks for Eddystone (UUID 0xFEAA) service data and decodes
+// its UID, URL, TLM or EID frame, identified by the top nibble of the first
+// byte.
+func decodeEddystone(serviceData map[string][]byte) (Beacon, bool) {
+	raw, ok := serviceData[eddystoneUUID]
+	if !ok || len(raw) < 1 {
+		return Beacon{}, false
+	}
+	switch raw[0] & 0xF0 {
+	case 0x00: // UID
+		if len(raw) < 18 {
+			return Beacon{}, false
+		}
+		return Beacon{
+			Type:      "eddystone-uid",
+			Namespace: hex.EncodeToString(raw[2:12]),
+			Instance:  hex.EncodeToString(raw[12:18]),
+		}, true
+	case 0x10: // URL
+		if len(raw) < 2 {
+			return Beacon{}, false
+		}
+		return Beacon{
+			Type: "eddystone-url",
+			URL:  decodeEddystoneURL(raw[2:]),
+		}, true
+	case 0x20: // TLM
+		if len(raw) < 14 {
+			return Beacon{}, false
+		}
+		return Beacon{
+			Type:        "eddystone-tlm",
+			Voltage:     binary.BigEndian.Uint16(raw[2:4]),
+			Temperature: float64(int8(raw[4])) + float64(raw[5])/256,
+			AdvCnt:      binary.BigEndian.Uint32(raw[6:10]),
+			SecCnt:      binary.BigEndian.Uint32(raw[10:14]),
+		}, true
+	case 0x30: // EID
+		if len(raw) < 10 {
+			return Beacon{}, false
+		}
+		return Beacon{
+			Type:     "eddystone-eid",
+			Instance: hex.EncodeToString(raw[2:10]),
+		}, true
+	}
+	return Beacon{}, false
+}
+
+// eddystoneURLSchemes is the Eddystone-URL scheme prefix table, indexed by
+// the first byte of the URL frame after the frame-type/TX-power bytes.
+var eddystoneURLSchemes = []string{
+	"http://www.",
+	"https://www.",
+	"http://",
+	"https://",
+}
+
+// eddystoneURLSuffixes is the Eddystone-URL HTTP URL encoding table, indexed
+// by any byte in [0x00, 0x0D] found in the encoded URL body.
+var eddystoneURLSuffixes = []string{
+	".com/", ".org/", ".edu/", ".net/", ".info/", ".biz/", ".gov/",
+	".com", ".org", ".edu", ".net", ".info", ".biz", ".gov",
+}
+
+// decodeEddystoneURL expands an Eddystone-URL frame body (TX power byte
+// already stripped) into its full URL using the scheme and suffix tables.
+func decodeEddystoneURL(value []byte) string {
+	if len(value) < 1 || int(value[0]) >= len(eddystoneURLSchemes) {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(eddystoneURLSchemes[value[0]])
+	for _, c := range value[1:] {
+		if int(c) < len(eddystoneURLSuffixes) {
+			b.WriteString(eddystoneURLSuffixes[c])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// formatUUIDBigEndian renders a 128-bit UUID in standard 8-4-4-4-12 textual
+// form from bytes already in big-endian (network) order, as iBeacon
+// transmits its proximity UUID. Unlike formatUUID128, no byte reversal is
+// applied.
+func formatUUIDBigEndian(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+// TestDecodeBeaconIBeaconFromAdvertisement decodes a realistic iBeacon
+// manufacturer-data AD payload end-to-end through parseGAP and
+// decodeBeacon, proving beacons are actually recognized once parseGAP is
+// fed the real AD payload rather than the raw HCI event.
+func TestDecodeBeaconIBeaconFromAdvertisement(t *testing.T) {
+	raw := []byte{
+		0x1a, 0xff, 0x4c, 0x00, // Manufacturer specific data, Apple (0x004c)
+		0x02, 0x15, // iBeacon prefix
+		0xe2, 0xc5, 0x6d, 0xb5, 0xdf, 0xfb, 0x48, 0xd2,
+		0xb0, 0x60, 0xd0, 0xf5, 0xa7, 0x10, 0x96, 0xe1, // proximity UUID
+		0x12, 0x34, // major
+		0x56, 0x78, // minor
+		0xc5, // measured power: -59 dBm
+	}
+
+	gap := parseGAP(raw)
+	b := decodeBeacon(gap, -65)
+	if b == nil {
+		t.Fatal("decodeBeacon: did not recognize iBeacon frame")
+	}
+	if b.Type != "ibeacon" {
+		t.Errorf("Type = %q, want ibeacon", b.Type)
+	}
+	if want := "e2c56db5-dffb-48d2-b060-d0f5a71096e1"; b.ProximityUUID != want {
+		t.Errorf("ProximityUUID = %q, want %q", b.ProximityUUID, want)
+	}
+	if b.Major != 0x1234 || b.Minor != 0x5678 {
+		t.Errorf("Major/Minor = %d/%d, want %d/%d", b.Major, b.Minor, 0x1234, 0x5678)
+	}
+	if b.MeasuredPower != -59 {
+		t.Errorf("MeasuredPower = %d, want -59", b.MeasuredPower)
+	}
+}
+
+// TestDecodeBeaconEddystoneURLFromAdvertisement decodes a realistic
+// Eddystone-URL service-data AD payload end-to-end through parseGAP and
+// decodeBeacon, guarding against the frame-type/TX-power stripping bug
+// that made decodeEddystoneURL read the TX power byte as the URL scheme.
+func TestDecodeBeaconEddystoneURLFromAdvertisement(t *testing.T) {
+	raw := []byte{
+		0x0e, 0x16, // Service Data, 16-bit UUID
+		0xaa, 0xfe, // Eddystone UUID (0xFEAA, little-endian)
+		0x10, // frame type: URL
+		0xee, // TX power: -18 dBm
+		0x02, // URL scheme: "http://"
+		'e', 'x', 'a', 'm', 'p', 'l', 'e',
+		0x00, // suffix: ".com/"
+	}
+
+	gap := parseGAP(raw)
+	b := decodeBeacon(gap, -65)
+	if b == nil {
+		t.Fatal("decodeBeacon: did not recognize Eddystone-URL frame")
+	}
+	if b.Type != "eddystone-url" {
+		t.Errorf("Type = %q, want eddystone-url", b.Type)
+	}
+	if want := "http://example.com/"; b.URL != want {
+		t.Errorf("URL = %q, want %q", b.URL, want)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vharitonsky/iniflags"
+)
+
+// Config flags. All are overridable via -config=/path/to/blueblue.conf (see
+// iniflags) and via BLUEBLUE_* environment variables, e.g. BLUEBLUE_EXPIRY.
+var (
+	dur         *time.Duration
+	dir         *string
+	port        *int
+	expiry      *time.Duration
+	maxDevices  *int
+	listenAddr  *string
+	tlsCert     *string
+	tlsKey      *string
+	logFile     *string
+	persistFile *string
+)
+
+// envPrefix namespaces BLUEBLUE_* environment variable overrides for flags.
+const envPrefix = "BLUEBLUE_"
+
+// loadConfig registers all flags and parses them from the command line, an
+// optional -config ini file (see iniflags), and BLUEBLUE_* environment
+// variables. Precedence, low to high: built-in default, environment,
+// config file, explicit command line flag.
+func loadConfig() {
+	d, err := os.Getwd()
+	if err != nil {
+		logger.Fatalf("Can't get running directory: %s", err)
+	}
+	d = filepath.Join(d, "public")
+
+	dir = flag.String("dir", d, "directory where the public directory is in")
+	dur = flag.Duration("d", 5*time.Second, "Scan duration")
+	port = flag.Int("p", 23232, "the port where the server starts")
+	expiry = flag.Duration("expiry", 60*time.Second, "how long a device can go unseen before it's dropped from the devices map")
+	maxDevices = flag.Int("max-devices", 0, "maximum number of tracked devices, 0 means unlimited")
+	listenAddr = flag.String("listen", "", "address to listen on, overrides 0.0.0.0:-p when set")
+	tlsCert = flag.String("tls-cert", "", "path to a TLS certificate, enables HTTPS together with -tls-key")
+	tlsKey = flag.String("tls-key", "", "path to a TLS private key")
+	logFile = flag.String("log-file", "blueblue.log", "path to the log file")
+	persistFile = flag.String("persist-file", "", "path to persist the devices map across restarts")
+
+	applyEnvDefaults()
+	iniflags.Parse()
+}
+
+// applyEnvDefaults pre-seeds flag values from BLUEBLUE_* environment
+// variables before iniflags.Parse() runs, so a config file or an explicit
+// command-line flag can still override them.
+func applyEnvDefaults() {
+	flag.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(val); err != nil {
+			logger.Warnf("config: invalid value %q for %s: %s", val, name, err)
+		}
+	})
+}
+
+// listenAddress returns the address serve() should bind to.
+func listenAddress() string {
+	if *listenAddr != "" {
+		return *listenAddr
+	}
+	return "0.0.0.0:" + strconv.Itoa(*port)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/yunginnanet/blueblue/internal/logging"
+)
+
+// GAP AD type identifiers, as defined by the Bluetooth Core Specification
+// Supplement, Part A, Section 1.
+const (
+	adTypeFlags                 = 0x01
+	adTypeIncomplete16BitUUIDs  = 0x02
+	adTypeComplete16BitUUIDs    = 0x03
+	adTypeIncomplete32BitUUIDs  = 0x04
+	adTypeComplete32BitUUIDs    = 0x05
+	adTypeIncomplete128BitUUIDs = 0x06
+	adTypeComplete128BitUUIDs   = 0x07
+	adTypeTxPowerLevel          = 0x0A
+	adTypeServiceData16         = 0x16
+	adTypeAppearance            = 0x19
+	adTypeServiceData32         = 0x20
+	adTypeServiceData128        = 0x21
+	adTypeManufacturerData      = 0xFF
+)
+
+// GAPData is the decoded form of the GAP advertising data (AD) structures
+// found in an LE advertising report and/or scan response.
+type GAPData struct {
+	Flags            byte
+	TxPower          int8
+	Appearance       uint16
+	ServiceUUIDs     []string
+	ServiceData      map[string][]byte
+	ManufacturerData map[uint16][]byte
+}
+
+// parseGAP walks one or more raw AD-structure buffers (length/type/value
+// TLV records) and merges everything it finds into a single GAPData. It
+// stops cleanly on a zero-length record or a truncated buffer rather than
+// panicking on malformed input.
+func parseGAP(raws ...[]byte) GAPData {
+	data := GAPData{
+		ServiceData:      make(map[string][]byte),
+		ManufacturerData: make(map[uint16][]byte),
+	}
+	for _, raw := range raws {
+		parseGAPInto(&data, raw)
+	}
+	return data
+}
+
+func parseGAPInto(data *GAPData, raw []byte) {
+	for i := 0; i < len(raw); {
+		length := int(raw[i])
+		if length == 0 {
+			return
+		}
+		if i+1+length > len(raw) {
+			logger.Debugf(logging.Parse, "truncated AD record at offset %d", i)
+			return
+		}
+		adType := raw[i+1]
+		value := raw[i+2 : i+1+length]
+		switch adType {
+		case adTypeFlags:
+			if len(value) >= 1 {
+				data.Flags = value[0]
+			}
+		case adTypeIncomplete16BitUUIDs, adTypeComplete16BitUUIDs:
+			for j := 0; j+2 <= len(value); j += 2 {
+				data.ServiceUUIDs = append(data.ServiceUUIDs, formatUUID16(binary.LittleEndian.Uint16(value[j:j+2])))
+			}
+		case adTypeIncomplete32BitUUIDs, adTypeComplete32BitUUIDs:
+			for j := 0; j+4 <= len(value); j += 4 {
+				data.ServiceUUIDs = append(data.ServiceUUIDs, formatUUID32(binary.LittleEndian.Uint32(value[j:j+4])))
+			}
+		case adTypeIncomplete128BitUUIDs, adTypeComplete128BitUUIDs:
+			for j := 0; j+16 <= len(value); j += 16 {
+				data.ServiceUUIDs = append(data.ServiceUUIDs, formatUUID128(value[j:j+16]))
+			}
+		case adTypeTxPowerLevel:
+			if len(value) >= 1 {
+				data.TxPower = int8(value[0])
+			}
+		case adTypeAppearance:
+			if len(value) >= 2 {
+				data.Appearance = binary.LittleEndian.Uint16(value[:2])
+			}
+		case adTypeServiceData16:
+			if len(value) >= 2 {
+				uuid := formatUUID16(binary.LittleEndian.Uint16(value[:2]))
+				data.ServiceData[uuid] = append([]byte{}, value[2:]...)
+			}
+		case adTypeServiceData32:
+			if len(value) >= 4 {
+				uuid := formatUUID32(binary.LittleEndian.Uint32(value[:4]))
+				data.ServiceData[uuid] = append([]byte{}, value[4:]...)
+			}
+		case adTypeServiceData128:
+			if len(value) >= 16 {
+				uuid := formatUUID128(value[:16])
+				data.ServiceData[uuid] = append([]byte{}, value[16:]...)
+			}
+		case adTypeManufacturerData:
+			if len(value) >= 2 {
+				id := binary.LittleEndian.Uint16(value[:2])
+				data.ManufacturerData[id] = append([]byte{}, value[2:]...)
+			}
+		}
+		i += 1 + length
+	}
+}
+
+// formatUUID16 renders a 16-bit service UUID the way serviceUUIDNames keys
+// are written, e.g. "180f".
+func formatUUID16(u uint16) string {
+	return fmt.Sprintf("%04x", u)
+}
+
+// formatUUID32 renders a 32-bit service UUID as 8 lowercase hex digits.
+func formatUUID32(u uint32) string {
+	return fmt.Sprintf("%08x", u)
+}
+
+// formatUUID128 renders a 128-bit service UUID in standard
+// 8-4-4-4-12 textual form. GAP transmits 128-bit UUIDs least-significant
+// octet first, so the bytes are reversed before formatting.
+func formatUUID128(b []byte) string {
+	rev := make([]byte, len(b))
+	for i, v := range b {
+		rev[len(b)-1-i] = v
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", rev[0:4], rev[4:6], rev[6:8], rev[8:10], rev[10:16])
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+// TestParseGAPRealisticAdvertisement feeds parseGAP a hand-built but
+// protocol-accurate AD payload (Flags, a 16-bit service UUID, TX power and
+// an iBeacon manufacturer-data frame) and checks every field round-trips.
+// This guards against parseGAP ever being fed the wrong slice again (e.g.
+// the raw HCI advertising-report event instead of the AD payload it
+// contains), which silently produced garbage Flags and nothing else.
+func TestParseGAPRealisticAdvertisement(t *testing.T) {
+	raw := []byte{
+		0x02, 0x01, 0x06, // Flags: LE General Discoverable, BR/EDR not supported
+		0x03, 0x03, 0x0d, 0x18, // Complete list of 16-bit UUIDs: 0x180d (Heart Rate)
+		0x02, 0x0a, 0xc5, // TX power level: -59 dBm
+		0x1a, 0xff, 0x4c, 0x00, // Manufacturer specific data, Apple (0x004c)
+		0x02, 0x15, // iBeacon prefix
+		0xe2, 0xc5, 0x6d, 0xb5, 0xdf, 0xfb, 0x48, 0xd2,
+		0xb0, 0x60, 0xd0, 0xf5, 0xa7, 0x10, 0x96, 0xe1, // proximity UUID
+		0x12, 0x34, // major
+		0x56, 0x78, // minor
+		0xc5, // measured power: -59 dBm
+	}
+
+	gap := parseGAP(raw)
+
+	if gap.Flags != 0x06 {
+		t.Errorf("Flags = %#x, want 0x06", gap.Flags)
+	}
+	if gap.TxPower != -59 {
+		t.Errorf("TxPower = %d, want -59", gap.TxPower)
+	}
+	if len(gap.ServiceUUIDs) != 1 || gap.ServiceUUIDs[0] != "180d" {
+		t.Errorf("ServiceUUIDs = %v, want [180d]", gap.ServiceUUIDs)
+	}
+	if mfg, ok := gap.ManufacturerData[appleCompanyID]; !ok || len(mfg) != 23 {
+		t.Fatalf("ManufacturerData[appleCompanyID] = %v, want 23-byte iBeacon frame", mfg)
+	}
+}
+
+// TestParseGAPMultipleBuffers checks that parseGAP merges AD records found
+// across the advertisement and scan response into a single GAPData, as
+// onAdvertisement relies on for devices that split fields across both.
+func TestParseGAPMultipleBuffers(t *testing.T) {
+	adv := []byte{0x02, 0x01, 0x06}      // Flags
+	scanResp := []byte{0x02, 0x0a, 0xec} // TX power level: -20 dBm
+	gap := parseGAP(adv, scanResp)
+
+	if gap.Flags != 0x06 {
+		t.Errorf("Flags = %#x, want 0x06", gap.Flags)
+	}
+	if gap.TxPower != -20 {
+		t.Errorf("TxPower = %d, want -20", gap.TxPower)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import "sync"
+
+// EventType describes how a Device changed between two advertisement
+// reports.
+type EventType string
+
+const (
+	// EventSeen is published the first time an address is observed.
+	EventSeen EventType = "seen"
+	// EventUpdated is published when a previously seen address reports
+	// again, e.g. with a new RSSI or advertisement payload.
+	EventUpdated EventType = "updated"
+	// EventExpired is published when a device ages out of the devices map.
+	EventExpired EventType = "expired"
+)
+
+// DeviceEvent is what gets fanned out to subscribers of the Hub.
+type DeviceEvent struct {
+	Type   EventType `json:"type"`
+	Device Device    `json:"device"`
+}
+
+// hubClientBuffer bounds how many unconsumed events a single client can
+// accumulate before it is considered slow and starts dropping events.
+const hubClientBuffer = 16
+
+// Hub fans DeviceEvents out to any number of subscribers (SSE or otherwise)
+// without letting a slow or stalled client block the scan handler that
+// publishes them.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[chan DeviceEvent]struct{}
+}
+
+func newHub() *Hub {
+	return &Hub{clients: make(map[chan DeviceEvent]struct{})}
+}
+
+// subscribe registers a new client and returns its event channel along with
+// an unsubscribe func that must be called once, when the client disconnects.
+func (h *Hub) subscribe() (<-chan DeviceEvent, func()) {
+	ch := make(chan DeviceEvent, hubClientBuffer)
+	h.mu.Lock()
+	h.clients[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.clients, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// publish fans evt out to every subscribed client. A client that isn't
+// keeping up has the event dropped rather than blocking the publisher.
+func (h *Hub) publish(evt DeviceEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- evt:
+		default:
+			logger.Warnf("hub: dropping event for slow client")
+		}
+	}
+}
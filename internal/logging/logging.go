@@ -0,0 +1,103 @@
+// Package logging provides a small leveled logger with per-subsystem debug
+// gating, so BLE parsing and scan traffic can be switched on in production
+// without a recompile.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Subsystem identifies a debug category that can be toggled independently
+// via the BLUETRACE environment variable.
+type Subsystem string
+
+// Subsystems understood by BLUETRACE.
+const (
+	Scan  Subsystem = "scan"
+	HTTP  Subsystem = "http"
+	HCI   Subsystem = "hci"
+	Parse Subsystem = "parse"
+)
+
+// EnvVar is the environment variable consulted for the initial set of
+// enabled debug subsystems, e.g. BLUETRACE=scan,http or BLUETRACE=all.
+const EnvVar = "BLUETRACE"
+
+// Logger is a leveled logger with Infof/Warnf/Fatalf plus a Debugf gated per
+// Subsystem.
+type Logger struct {
+	mu    sync.RWMutex
+	out   *log.Logger
+	debug map[Subsystem]bool
+	all   bool
+}
+
+// New creates a Logger that writes to w with the given prefix. Its initial
+// set of enabled debug subsystems is read from the BLUETRACE env var.
+func New(w io.Writer, prefix string) *Logger {
+	l := &Logger{
+		out:   log.New(w, prefix, log.LstdFlags|log.Lshortfile),
+		debug: make(map[Subsystem]bool),
+	}
+	l.SetTrace(os.Getenv(EnvVar))
+	return l
+}
+
+// SetTrace replaces the set of enabled debug subsystems, parsing the same
+// comma-separated format as BLUETRACE (e.g. "scan,http" or "all").
+func (l *Logger) SetTrace(val string) {
+	debug := make(map[Subsystem]bool)
+	all := false
+	for _, part := range strings.Split(val, ",") {
+		part = strings.TrimSpace(strings.ToLower(part))
+		if part == "" {
+			continue
+		}
+		if part == "all" {
+			all = true
+			continue
+		}
+		debug[Subsystem(part)] = true
+	}
+	l.mu.Lock()
+	l.debug = debug
+	l.all = all
+	l.mu.Unlock()
+}
+
+// Enabled reports whether debug output for sub is currently switched on.
+func (l *Logger) Enabled(sub Subsystem) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.all || l.debug[sub]
+}
+
+// Infof logs an informational message.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.out.Output(2, "INFO  "+fmt.Sprintf(format, args...))
+}
+
+// Warnf logs a warning.
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.out.Output(2, "WARN  "+fmt.Sprintf(format, args...))
+}
+
+// Debugf logs a debug message for sub, but only when sub (or "all") is
+// enabled via BLUETRACE/SetTrace.
+func (l *Logger) Debugf(sub Subsystem, format string, args ...interface{}) {
+	if !l.Enabled(sub) {
+		return
+	}
+	l.out.Output(2, fmt.Sprintf("DEBUG[%s] ", sub)+fmt.Sprintf(format, args...))
+}
+
+// Fatalf logs an error and exits the process with status 1.
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.out.Output(2, "FATAL "+fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
@@ -2,32 +2,28 @@ package main
 
 import (
 	"context"
-	"encoding/hex"
-	"flag"
+	"encoding/json"
+	"fmt"
 	"html/template"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 	"unicode"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sausheong/ble"
 	"github.com/sausheong/ble/linux"
+	"github.com/yunginnanet/blueblue/internal/logging"
 )
 
 var (
-	dur       *time.Duration
-	dir       *string
-	port      *int
-	logger         = log.Default()
-	stop      bool = true
-	templates      = map[string]*template.Template{}
+	logger    = logging.New(os.Stdout, "blueblue: ")
+	templates = map[string]*template.Template{}
+	scanner   = newScanner()
 )
 
 // Device represents a BLE device
@@ -39,61 +35,62 @@ type Device struct {
 	RSSI          int       `json:"rssi"`
 	Advertisement string    `json:"advertisement"`
 	ScanResponse  string    `json:"scanresponse"`
-}
 
-var mutex sync.RWMutex
-var devices map[string]Device
+	// Fields decoded from the GAP advertising data (AD) structures found
+	// in the advertisement and scan response, see gap.go.
+	Flags            byte              `json:"flags,omitempty"`
+	TxPower          int8              `json:"txpower,omitempty"`
+	Appearance       uint16            `json:"appearance,omitempty"`
+	ServiceUUIDs     []string          `json:"serviceuuids,omitempty"`
+	ServiceData      map[string][]byte `json:"servicedata,omitempty"`
+	ManufacturerData map[uint16][]byte `json:"manufacturerdata,omitempty"`
+	VendorName       string            `json:"vendorname,omitempty"`
+
+	// Beacon holds a decoded iBeacon/Eddystone frame, if one was recognized
+	// in the advertisement, see beacon.go.
+	Beacon *Beacon `json:"beacon,omitempty"`
+}
 
-func init() {
-	logger.Println("init")
-	devices = make(map[string]Device)
-	mutex = sync.RWMutex{}
-	d, err := os.Getwd()
-	if err != nil {
-		log.Fatal("Can't get running directory:", err)
-	}
-	d = filepath.Join(d, "public")
-	dir = flag.String("dir", d, "directory where the public directory is in")
-	dur = flag.Duration("d", 5*time.Second, "Scan duration")
-	port = flag.Int("p", 23232, "the port where the server starts")
-	flag.Parse()
+func main() {
+	loadConfig()
 	templates["index"], _ = template.ParseFiles(filepath.Join(*dir, "index.html"))
 	templates["devices"], _ = template.ParseFiles(filepath.Join(*dir, "devices.html"))
-}
 
-func main() {
-	f, err := os.OpenFile("blueblue.log",
+	f, err := os.OpenFile(*logFile,
 		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Println(err)
+		logger.Warnf("Can't open log file: %s", err)
 	}
 	// defer f.Close()
 
 	w := io.MultiWriter(f, os.Stdout)
-	logger = log.New(w, "blueblue: ", log.LstdFlags)
+	logger = logging.New(w, "blueblue: ")
+
+	loadPersisted()
+	go waitForShutdown()
 
 	d, err := linux.NewDevice()
 	if err != nil {
-		logger.Fatal("Can't create new device:", err)
+		logger.Fatalf("Can't create new device: %s", err)
 	}
 	ble.SetDefaultDevice(d)
-	logger.Printf("Starting blueblue server, using device: %s\n", d.Address().String())
+	logger.Infof("Starting blueblue server, using device: %s", d.Address().String())
 	serve()
 }
 
-// Handle the advertisement scan
-func adScanHandler(a ble.Advertisement) {
-	mutex.Lock()
-	device := Device{
-		Address:       a.Addr().String(),
-		Detected:      time.Now(),
-		Name:          clean(a.LocalName()),
-		RSSI:          a.RSSI(),
-		Advertisement: formatHex(hex.EncodeToString(a.LEAdvertisingReportRaw())),
-		ScanResponse:  formatHex(hex.EncodeToString(a.ScanResponseRaw())),
+// janitorInterval is how often janitor() sweeps devices for expired
+// entries. It's independent of *expiry, which controls how old an entry
+// has to be before it's considered stale.
+const janitorInterval = 5 * time.Second
+
+// janitor periodically removes devices that have aged past *expiry, so the
+// devices map doesn't grow unbounded during a long scan.
+func janitor() {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		scanner.expireStale(time.Now().Add(-*expiry))
 	}
-	devices[a.Addr().String()] = device
-	mutex.Unlock()
 }
 
 // start the web server
@@ -104,35 +101,42 @@ func serve() {
 	mux.HandleFunc("/stop", stopScan)
 	mux.HandleFunc("/start", startScan)
 	mux.HandleFunc("/devices", showDevices)
+	mux.HandleFunc("/stream", streamHandler)
+	mux.HandleFunc("/beacons", beaconsHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/api/v1/devices", apiDevicesHandler)
+	mux.HandleFunc("/api/v1/devices/", apiDeviceHandler)
+	mux.HandleFunc("/api/v1/scan", apiScanHandler)
 	server := &http.Server{
-		Addr:    "0.0.0.0:" + strconv.Itoa(*port),
+		Addr:    listenAddress(),
 		Handler: mux,
 	}
-	logger.Println("Started blueblue server at", server.Addr)
+	go janitor()
+	logger.Infof("Started blueblue server at %s", server.Addr)
+	if *tlsCert != "" && *tlsKey != "" {
+		server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		return
+	}
 	server.ListenAndServe()
 }
 
 // index for web server
 func index(w http.ResponseWriter, r *http.Request) {
-	if err := templates["index"].Execute(w, stop); err != nil {
+	if err := templates["index"].Execute(w, !scanner.Running()); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Println("Error executing template:", err)
+		logger.Warnf("Error executing template: %s", err)
 	}
+	logger.Debugf(logging.HTTP, "served / to %s", r.RemoteAddr)
 }
 
 // handler to show list of devices
 func showDevices(w http.ResponseWriter, r *http.Request) {
 	t := templates["devices"]
 
-	// convert map to array, added detect since duration and
-	// remove anything that's more than 60 seconds
-	data := []Device{}
-	for _, device := range devices {
-		device.Since = strconv.Itoa(int(time.Since(device.Detected).Seconds()))
-		tn := time.Now().Add(-1 * time.Duration(60) * time.Second)
-		if tn.Before(device.Detected) {
-			data = append(data, device)
-		}
+	// the janitor goroutine is what keeps this list free of stale devices.
+	data := scanner.Devices()
+	for i := range data {
+		data[i].Since = since(data[i].Detected)
 	}
 	// sort by RSSI
 	sort.SliceStable(data, func(i, j int) bool {
@@ -140,38 +144,86 @@ func showDevices(w http.ResponseWriter, r *http.Request) {
 	})
 	if err := t.Execute(w, data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Println("Error executing template:", err)
+		logger.Warnf("Error executing template: %s", err)
+	}
+	logger.Debugf(logging.HTTP, "served /devices to %s (%d devices)", r.RemoteAddr, len(data))
+}
+
+// streamHandler streams device events to the client as Server-Sent Events,
+// so the UI no longer needs to poll /devices for changes.
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	events, unsubscribe := scanner.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(evt)
+			if err != nil {
+				logger.Warnf("stream: error marshaling event: %s", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, payload)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// beaconsHandler returns only devices with a recognized iBeacon/Eddystone
+// frame, sorted by estimated distance (nearest first). Devices without a
+// computable distance (e.g. Eddystone frames) sort last.
+func beaconsHandler(w http.ResponseWriter, r *http.Request) {
+	data := make([]Device, 0)
+	for _, device := range scanner.Devices() {
+		if device.Beacon != nil {
+			data = append(data, device)
+		}
+	}
+
+	sort.SliceStable(data, func(i, j int) bool {
+		di, dj := data[i].Beacon.Distance, data[j].Beacon.Distance
+		if di == 0 {
+			return false
+		}
+		if dj == 0 {
+			return true
+		}
+		return di < dj
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.Warnf("Error encoding beacons: %s", err)
 	}
 }
 
 // handler to start scanning
 func startScan(w http.ResponseWriter, r *http.Request) {
-	if !stop {
-		w.WriteHeader(409)
-	} else {
-		go scan()
+	if err := scanner.Start(context.Background(), *dur); err != nil {
+		writeAPIError(w, http.StatusConflict, err.Error())
 	}
 }
 
 // handler to stop scanning
 func stopScan(w http.ResponseWriter, r *http.Request) {
-	if stop {
-		w.WriteHeader(409)
-	} else {
-		stop = true
-	}
-}
-
-// scan goroutine
-func scan() {
-	stop = false
-	logger.Println("Started scanning every", *dur)
-	for !stop {
-		ctx := ble.WithSigHandler(context.WithTimeout(context.Background(), *dur))
-		ble.Scan(ctx, false, adScanHandler, nil)
+	if err := scanner.Stop(); err != nil {
+		writeAPIError(w, http.StatusConflict, err.Error())
 	}
-	logger.Println("Stopped scanning.")
-	stop = true
 }
 
 // reformat string for proper display of hex
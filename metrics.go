@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus collectors exposed on /metrics, so blueblue can be scraped by
+// external tools rather than only driven through its HTML UI.
+var (
+	devicesSeenTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "blueblue_devices_seen_total",
+		Help: "Total number of distinct device addresses seen since startup.",
+	})
+	devicesActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "blueblue_devices_active",
+		Help: "Number of devices currently tracked in the devices map.",
+	})
+	scanDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "blueblue_scan_duration_seconds",
+		Help: "Observed duration of each completed scan pass.",
+	})
+	advertisementBytesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "blueblue_advertisement_bytes_total",
+		Help: "Total bytes of raw advertisement + scan response payloads processed.",
+	})
+	deviceRSSI = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "blueblue_rssi",
+		Help: "Most recently observed RSSI for a device.",
+	}, []string{"addr", "name"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		devicesSeenTotal,
+		devicesActive,
+		scanDurationSeconds,
+		advertisementBytesTotal,
+		deviceRSSI,
+	)
+}
+
+// observeAdvertisement updates the Prometheus collectors for a single
+// processed advertisement.
+func observeAdvertisement(device Device, existed bool, advBytes, scanRespBytes int) {
+	if !existed {
+		devicesSeenTotal.Inc()
+	}
+	advertisementBytesTotal.Add(float64(advBytes + scanRespBytes))
+	deviceRSSI.WithLabelValues(device.Address, device.Name).Set(float64(device.RSSI))
+}
+
+// observeScan records how long a completed scan pass took.
+func observeScan(d time.Duration) {
+	scanDurationSeconds.Observe(d.Seconds())
+}
+
+// updateDevicesActive refreshes the blueblue_devices_active gauge to n.
+func updateDevicesActive(n int) {
+	devicesActive.Set(float64(n))
+}
+
+// since is a small formatting helper shared by the JSON API and HTML
+// handlers for the "seconds since last seen" field.
+func since(t time.Time) string {
+	return strconv.Itoa(int(time.Since(t).Seconds()))
+}
@@ -0,0 +1,57 @@
+package main
+
+// serviceUUIDNames maps well-known 16-bit service UUIDs, formatted the same
+// way formatUUID16 renders them, to their Bluetooth SIG assigned names. It
+// isn't exhaustive, just the ones blueblue is most likely to see in the
+// wild.
+var serviceUUIDNames = map[string]string{
+	"1800": "Generic Access",
+	"1801": "Generic Attribute",
+	"180a": "Device Information",
+	"180d": "Heart Rate",
+	"180f": "Battery Service",
+	"181c": "User Data",
+	"fd6f": "Exposure Notification",
+	"fe9f": "Google",
+	"feaa": "Eddystone",
+}
+
+// companyNames maps Bluetooth SIG assigned company identifiers (as decoded
+// from manufacturer-specific AD data) to vendor names.
+var companyNames = map[uint16]string{
+	0x0006: "Microsoft",
+	0x004C: "Apple",
+	0x0075: "Samsung",
+	0x00E0: "Google",
+	0x0157: "Xiaomi",
+	0x0171: "Amazon",
+}
+
+// deviceVendorName picks a human-readable name for a device from its
+// decoded manufacturer data or well-known service UUIDs, preferring
+// manufacturer data since it identifies the actual chipset/vendor.
+func deviceVendorName(gap GAPData) string {
+	for id := range gap.ManufacturerData {
+		if name := companyName(id); name != "" {
+			return name
+		}
+	}
+	for _, uuid := range gap.ServiceUUIDs {
+		if name := serviceUUIDName(uuid); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// serviceUUIDName returns the human-readable name for a well-known 16-bit
+// service UUID, or "" if it isn't recognized.
+func serviceUUIDName(uuid string) string {
+	return serviceUUIDNames[uuid]
+}
+
+// companyName returns the human-readable vendor name for a Bluetooth SIG
+// company identifier, or "" if it isn't recognized.
+func companyName(id uint16) string {
+	return companyNames[id]
+}
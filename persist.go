@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// loadPersisted restores a previously persisted devices map from
+// *persistFile, if one is configured and exists.
+func loadPersisted() {
+	if *persistFile == "" {
+		return
+	}
+	data, err := os.ReadFile(*persistFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("persist: couldn't read %s: %s", *persistFile, err)
+		}
+		return
+	}
+	loaded := make(map[string]Device)
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		logger.Warnf("persist: couldn't parse %s: %s", *persistFile, err)
+		return
+	}
+	scanner.replaceDevices(loaded)
+	logger.Infof("persist: restored %d devices from %s", len(loaded), *persistFile)
+}
+
+// savePersisted serializes the current devices map to *persistFile, if one
+// is configured.
+func savePersisted() {
+	if *persistFile == "" {
+		return
+	}
+	snapshot := scanner.snapshotForPersist()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		logger.Warnf("persist: couldn't marshal devices: %s", err)
+		return
+	}
+	if err := os.WriteFile(*persistFile, data, 0644); err != nil {
+		logger.Warnf("persist: couldn't write %s: %s", *persistFile, err)
+		return
+	}
+	logger.Infof("persist: saved %d devices to %s", len(snapshot), *persistFile)
+}
+
+// waitForShutdown blocks until SIGINT/SIGTERM, persists the devices map,
+// then exits.
+func waitForShutdown() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	<-ch
+	logger.Infof("shutting down")
+	savePersisted()
+	os.Exit(0)
+}
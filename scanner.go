@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sausheong/ble"
+	"github.com/sausheong/ble/linux/hci"
+	"github.com/yunginnanet/blueblue/internal/logging"
+)
+
+// ErrScanRunning is returned by Start when a scan is already in progress.
+var ErrScanRunning = errors.New("scan already running")
+
+// ErrScanNotRunning is returned by Stop when no scan is in progress.
+var ErrScanNotRunning = errors.New("no scan running")
+
+// Scanner owns the devices map and drives the BLE scan loop. It replaces
+// the old unsynchronized package-level stop bool: Start, Stop and Running
+// are all safe to call concurrently, and Start never leaves an orphaned
+// scan goroutine running if it's called twice in quick succession.
+type Scanner struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	running bool
+	handler ble.AdvHandler
+
+	devMu   sync.RWMutex
+	devices map[string]Device
+
+	hub *Hub
+}
+
+// newScanner creates a Scanner that processes advertisements through its
+// own onAdvertisement method.
+func newScanner() *Scanner {
+	s := &Scanner{
+		devices: make(map[string]Device),
+		hub:     newHub(),
+	}
+	s.handler = s.onAdvertisement
+	return s
+}
+
+// Running reports whether a scan is currently in progress.
+func (s *Scanner) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// Start begins repeated scan passes of duration d, each run under ctx,
+// until Stop is called or ctx is done. It returns ErrScanRunning instead of
+// starting a second, overlapping scan goroutine.
+func (s *Scanner) Start(ctx context.Context, d time.Duration) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return ErrScanRunning
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.running = true
+	s.mu.Unlock()
+
+	go s.run(runCtx, d)
+	return nil
+}
+
+// Stop cancels the in-flight scan pass immediately, rather than waiting for
+// its current duration to elapse.
+func (s *Scanner) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return ErrScanNotRunning
+	}
+	s.cancel()
+	return nil
+}
+
+// Subscribe registers a new client for device events, used by the SSE hub.
+// The returned func must be called once, when the client disconnects.
+func (s *Scanner) Subscribe() (<-chan DeviceEvent, func()) {
+	return s.hub.subscribe()
+}
+
+// run repeatedly scans for d at a time until ctx is done, which happens
+// when Stop cancels it or the caller-supplied ctx is canceled/expires.
+func (s *Scanner) run(ctx context.Context, d time.Duration) {
+	logger.Infof("Started scanning every %s", d)
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.cancel = nil
+		s.mu.Unlock()
+		logger.Infof("Stopped scanning.")
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		start := time.Now()
+		passCtx, cancel := context.WithTimeout(ctx, d)
+		logger.Debugf(logging.Scan, "scanning for %s", d)
+		err := ble.Scan(passCtx, false, s.handler, nil)
+		cancel()
+		observeScan(time.Since(start))
+
+		if err != nil && ctx.Err() == nil {
+			logger.Warnf("scan: %s", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// onAdvertisement decodes a BLE advertisement into a Device, records it,
+// and publishes a DeviceEvent to subscribers.
+func (s *Scanner) onAdvertisement(a ble.Advertisement) {
+	addr := a.Addr().String()
+	advRaw, scanRespRaw := a.LEAdvertisingReportRaw(), a.ScanResponseRaw()
+	gap := parseGAP(adData(a), scanRespRaw)
+	device := Device{
+		Address:          addr,
+		Detected:         time.Now(),
+		Name:             clean(a.LocalName()),
+		RSSI:             a.RSSI(),
+		Advertisement:    formatHex(hex.EncodeToString(advRaw)),
+		ScanResponse:     formatHex(hex.EncodeToString(scanRespRaw)),
+		Flags:            gap.Flags,
+		TxPower:          gap.TxPower,
+		Appearance:       gap.Appearance,
+		ServiceUUIDs:     gap.ServiceUUIDs,
+		ServiceData:      gap.ServiceData,
+		ManufacturerData: gap.ManufacturerData,
+		VendorName:       deviceVendorName(gap),
+	}
+	device.Beacon = decodeBeacon(gap, device.RSSI)
+
+	existed := s.setDevice(addr, device)
+	observeAdvertisement(device, existed, len(advRaw), len(scanRespRaw))
+
+	evtType := EventUpdated
+	if !existed {
+		evtType = EventSeen
+	}
+	logger.Debugf(logging.Scan, "%s %s rssi=%d", evtType, addr, device.RSSI)
+	s.hub.publish(DeviceEvent{Type: evtType, Device: device})
+}
+
+// adData returns the raw GAP advertising data (AD) payload for a, i.e. the
+// bytes parseGAP expects. ble.Advertisement itself only exposes
+// LEAdvertisingReportRaw(), which is the whole HCI event (subevent code,
+// report count, address and length headers, RSSI trailer) rather than the
+// AD structures inside it; the real payload is only reachable through the
+// Linux-specific *hci.Advertisement.Data(). Advertisements that don't
+// implement it fall back to an empty payload rather than misparsing the
+// HCI event.
+func adData(a ble.Advertisement) []byte {
+	if la, ok := a.(*hci.Advertisement); ok {
+		return la.Data()
+	}
+	return nil
+}
+
+// Devices returns a snapshot of every currently tracked device.
+func (s *Scanner) Devices() []Device {
+	s.devMu.RLock()
+	defer s.devMu.RUnlock()
+	out := make([]Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		out = append(out, d)
+	}
+	return out
+}
+
+// Device looks up a single tracked device by address.
+func (s *Scanner) Device(addr string) (Device, bool) {
+	s.devMu.RLock()
+	defer s.devMu.RUnlock()
+	d, ok := s.devices[addr]
+	return d, ok
+}
+
+// Count returns the number of currently tracked devices.
+func (s *Scanner) Count() int {
+	s.devMu.RLock()
+	defer s.devMu.RUnlock()
+	return len(s.devices)
+}
+
+// setDevice stores d under addr, reporting whether addr was already
+// present, and evicts the oldest entries once *maxDevices is exceeded.
+func (s *Scanner) setDevice(addr string, d Device) (existed bool) {
+	s.devMu.Lock()
+	_, existed = s.devices[addr]
+	s.devices[addr] = d
+	s.enforceMaxDevices()
+	updateDevicesActive(len(s.devices))
+	s.devMu.Unlock()
+	return existed
+}
+
+// enforceMaxDevices evicts the oldest-seen devices once the map grows past
+// *maxDevices. Callers must hold devMu.
+func (s *Scanner) enforceMaxDevices() {
+	if *maxDevices <= 0 || len(s.devices) <= *maxDevices {
+		return
+	}
+	for len(s.devices) > *maxDevices {
+		var oldestAddr string
+		var oldest time.Time
+		for addr, device := range s.devices {
+			if oldest.IsZero() || device.Detected.Before(oldest) {
+				oldestAddr, oldest = addr, device.Detected
+			}
+		}
+		deviceRSSI.DeleteLabelValues(oldestAddr, s.devices[oldestAddr].Name)
+		delete(s.devices, oldestAddr)
+	}
+}
+
+// expireStale removes every device last seen before cutoff, publishing
+// EventExpired for each. Used by janitor().
+func (s *Scanner) expireStale(cutoff time.Time) {
+	s.devMu.Lock()
+	for addr, device := range s.devices {
+		if device.Detected.Before(cutoff) {
+			deviceRSSI.DeleteLabelValues(addr, device.Name)
+			delete(s.devices, addr)
+			s.hub.publish(DeviceEvent{Type: EventExpired, Device: device})
+		}
+	}
+	updateDevicesActive(len(s.devices))
+	s.devMu.Unlock()
+}
+
+// replaceDevices atomically swaps in a previously persisted devices map, see
+// loadPersisted in persist.go.
+func (s *Scanner) replaceDevices(m map[string]Device) {
+	s.devMu.Lock()
+	s.devices = m
+	s.devMu.Unlock()
+}
+
+// snapshotForPersist returns a copy of the devices map for serialization,
+// see savePersisted in persist.go.
+func (s *Scanner) snapshotForPersist() map[string]Device {
+	s.devMu.RLock()
+	defer s.devMu.RUnlock()
+	out := make(map[string]Device, len(s.devices))
+	for k, v := range s.devices {
+		out[k] = v
+	}
+	return out
+}
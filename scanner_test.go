@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScannerStartTwiceReturnsErrScanRunning(t *testing.T) {
+	s := newScanner()
+	if err := s.Start(context.Background(), time.Second); err != nil {
+		t.Fatalf("first Start: %v", err)
+	}
+	defer s.Stop()
+
+	if err := s.Start(context.Background(), time.Second); err != ErrScanRunning {
+		t.Fatalf("second Start: got %v, want ErrScanRunning", err)
+	}
+}
+
+func TestScannerStopWhenNotRunning(t *testing.T) {
+	s := newScanner()
+	if err := s.Stop(); err != ErrScanNotRunning {
+		t.Fatalf("Stop: got %v, want ErrScanNotRunning", err)
+	}
+}
+
+// TestScannerConcurrentStartStop hammers Start/Stop from many goroutines at
+// once and checks that no scan goroutine is left running afterwards, i.e.
+// that Start never leaks an overlapping scan when hit twice in quick
+// succession.
+func TestScannerConcurrentStartStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	s := newScanner()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = s.Start(context.Background(), 10*time.Millisecond)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = s.Stop()
+		}()
+	}
+	wg.Wait()
+
+	// A scan may still be in flight from the very last Start call.
+	_ = s.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Running() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.Running() {
+		t.Fatal("scanner still reports running after Stop")
+	}
+
+	// Give the now-exiting scan goroutine a moment to actually return.
+	for time.Now().Before(deadline) && runtime.NumGoroutine() > before {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := runtime.NumGoroutine(); got > before {
+		t.Errorf("goroutine leak: started with %d, ended with %d", before, got)
+	}
+}